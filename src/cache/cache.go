@@ -0,0 +1,35 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides pluggable storage for a fetched Ignition config,
+// so that Engine.acquireConfig doesn't need to refetch from the providers
+// every time it's asked for a config it already has.
+package cache
+
+import "github.com/coreos/ignition/config"
+
+// ConfigStore persists a fetched config between calls to Engine.Run.
+type ConfigStore interface {
+	// Load returns the previously-saved config, or an error if nothing has
+	// been saved yet (or the save is no longer valid), in which case the
+	// caller is expected to fetch again and Save the result.
+	Load() (config.Config, error)
+
+	// Save persists cfg so that a later Load returns it.
+	Save(config.Config) error
+
+	// Invalidate discards anything previously saved, forcing the next
+	// Load to fail.
+	Invalidate() error
+}
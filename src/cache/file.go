@@ -0,0 +1,70 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/ignition/config"
+)
+
+// FileStore persists the config as JSON at a fixed path on disk. It's the
+// original ConfigStore and remains the default: it works on a single node
+// with no external dependencies.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore returns a ConfigStore backed by the JSON file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// Load returns ErrNotCached, rather than the underlying os error, when the
+// file doesn't exist yet, so callers can tell "nothing saved" apart from
+// an on-disk file that exists but fails to parse.
+func (s *FileStore) Load() (config.Config, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config.Config{}, ErrNotCached
+		}
+		return config.Config{}, err
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return config.Config{}, fmt.Errorf("cache: parsing cached config at %s: %v", s.Path, err)
+	}
+	return cfg, nil
+}
+
+func (s *FileStore) Save(cfg config.Config) error {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.Path, b, 0640)
+}
+
+func (s *FileStore) Invalidate() error {
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-filestore-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewFileStore(filepath.Join(dir, "config.json"))
+
+	if _, err := s.Load(); err != ErrNotCached {
+		t.Fatalf("Load before Save: err = %v, want ErrNotCached", err)
+	}
+
+	want := config.Config{}
+	if err := s.Save(want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Load = %+v, want %+v", got, want)
+	}
+}
+
+func TestFileStoreInvalidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-filestore-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewFileStore(filepath.Join(dir, "config.json"))
+
+	if err := s.Save(config.Config{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := s.Invalidate(); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	if _, err := s.Load(); err != ErrNotCached {
+		t.Fatalf("Load after Invalidate: err = %v, want ErrNotCached", err)
+	}
+}
+
+func TestFileStoreLoadCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-filestore-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte("not json"), 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s := NewFileStore(path)
+	if _, err := s.Load(); err == nil || err == ErrNotCached {
+		t.Fatalf("Load of a corrupt file: err = %v, want a non-nil error other than ErrNotCached", err)
+	}
+}
+
+func TestFileStoreInvalidateMissingFileIsNotAnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-filestore-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewFileStore(filepath.Join(dir, "does-not-exist.json"))
+	if err := s.Invalidate(); err != nil {
+		t.Fatalf("Invalidate on a store that was never Saved: %v", err)
+	}
+}
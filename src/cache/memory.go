@@ -0,0 +1,59 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/coreos/ignition/config"
+)
+
+// ErrNotCached is returned by Load when nothing has been saved yet.
+var ErrNotCached = errors.New("cache: no config has been saved")
+
+// MemoryStore is a ConfigStore that keeps the config in memory for the
+// lifetime of the process. It's meant for tests, where touching disk (or
+// etcd) would just add noise and flakiness.
+type MemoryStore struct {
+	mu  sync.Mutex
+	cfg *config.Config
+}
+
+func (s *MemoryStore) Load() (config.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg == nil {
+		return config.Config{}, ErrNotCached
+	}
+	return *s.cfg, nil
+}
+
+func (s *MemoryStore) Save(cfg config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = &cfg
+	return nil
+}
+
+func (s *MemoryStore) Invalidate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = nil
+	return nil
+}
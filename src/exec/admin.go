@@ -0,0 +1,179 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/ignition/src/metrics"
+	"github.com/coreos/ignition/src/providers"
+)
+
+// ProviderStatus is a point-in-time snapshot of one provider's polling
+// state, as last observed by aggregateProviders.
+type ProviderStatus struct {
+	Name              string        `json:"name"`
+	Online            bool          `json:"online"`
+	LastError         string        `json:"lastError,omitempty"`
+	Attempts          int           `json:"attempts"`
+	CumulativeBackoff time.Duration `json:"cumulativeBackoff"`
+}
+
+// StageStatus records whether a stage has run and, if so, whether it
+// succeeded.
+type StageStatus struct {
+	Ran bool `json:"ran"`
+	Ok  bool `json:"ok"`
+}
+
+// AdminState is the mutex-guarded state backing the optional admin HTTP
+// server: the latest ProviderStatus for each provider aggregateProviders
+// has polled, and the latest StageStatus for each stage Run has executed.
+// It's written from the provider-polling goroutines and from Run, and
+// read from the HTTP handler goroutines, so every access goes through mu.
+type AdminState struct {
+	mu        sync.Mutex
+	providers map[string]ProviderStatus
+	stages    map[string]StageStatus
+}
+
+func newAdminState() *AdminState {
+	return &AdminState{
+		providers: map[string]ProviderStatus{},
+		stages:    map[string]StageStatus{},
+	}
+}
+
+// recordProvider stores the latest status for provider. It's a no-op on a
+// nil *AdminState so callers that don't go through Engine.Init (and so
+// have no admin state at all) don't need to special-case it.
+func (a *AdminState) recordProvider(provider providers.Provider, online bool, attempts int, cumulativeBackoff time.Duration) {
+	if a == nil {
+		return
+	}
+
+	status := ProviderStatus{
+		Name:              provider.Name(),
+		Online:            online,
+		Attempts:          attempts,
+		CumulativeBackoff: cumulativeBackoff,
+	}
+	if er, ok := provider.(providers.ErrorReporter); ok {
+		if err := er.LastError(); err != nil {
+			status.LastError = err.Error()
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.providers[status.Name] = status
+}
+
+func (a *AdminState) recordStage(name string, ok bool) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stages[name] = StageStatus{Ran: true, Ok: ok}
+}
+
+// providerStatuses returns every recorded ProviderStatus, sorted by name.
+func (a *AdminState) providerStatuses() []ProviderStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]ProviderStatus, 0, len(a.providers))
+	for _, status := range a.providers {
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// stageStatus returns the recorded StageStatus for name, and whether the
+// stage has run at all.
+func (a *AdminState) stageStatus(name string) (StageStatus, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status, ok := a.stages[name]
+	return status, ok
+}
+
+// ServeAdmin starts the admin HTTP server on e.AdminAddress and blocks
+// until it exits, so it's normally run in its own goroutine (Init does
+// this automatically whenever AdminAddress is set). It exposes read-only
+// endpoints useful for debugging first-boot failures without SSH:
+//
+//   - /providers lists each registered provider's ProviderStatus;
+//   - /config returns the currently cached config, with secrets redacted;
+//   - /stages/<name>/status returns the named stage's StageStatus;
+//   - /metrics serves the Prometheus metrics described in src/metrics.
+func (e Engine) ServeAdmin() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/providers", e.handleProviders)
+	mux.HandleFunc("/config", e.handleConfig)
+	mux.HandleFunc("/stages/", e.handleStageStatus)
+	mux.Handle("/metrics", metrics.Handler())
+	return http.ListenAndServe(e.AdminAddress, mux)
+}
+
+func (e Engine) handleProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, e.admin.providerStatuses())
+}
+
+func (e Engine) handleConfig(w http.ResponseWriter, r *http.Request) {
+	cfg, err := e.ConfigCache.Load()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, redact(cfg))
+}
+
+func (e Engine) handleStageStatus(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/status") {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/stages/")
+	name = strings.TrimSuffix(name, "/status")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	status, ran := e.admin.stageStatus(name)
+	if !ran {
+		http.Error(w, "stage "+name+" has not run", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStageStatusUnknownStage(t *testing.T) {
+	e := Engine{}.Init()
+
+	w := httptest.NewRecorder()
+	e.handleStageStatus(w, httptest.NewRequest(http.MethodGet, "/stages/disks/status", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d for a stage that has never run", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleStageStatusKnownStage(t *testing.T) {
+	e := Engine{}.Init()
+	e.admin.recordStage("disks", true)
+
+	w := httptest.NewRecorder()
+	e.handleStageStatus(w, httptest.NewRequest(http.MethodGet, "/stages/disks/status", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var got StageStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if want := (StageStatus{Ran: true, Ok: true}); got != want {
+		t.Fatalf("status = %+v, want %+v", got, want)
+	}
+}
+
+func TestHandleStageStatusPathEdgeCases(t *testing.T) {
+	e := Engine{}.Init()
+	e.admin.recordStage("disks", true)
+
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/stages//status", http.StatusNotFound},
+		{"/stages/disks", http.StatusNotFound},
+		{"/stages/", http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		e.handleStageStatus(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.want {
+			t.Errorf("GET %s: status = %d, want %d", c.path, w.Code, c.want)
+		}
+	}
+}
+
+func TestAdminStateProviderStatusesSorted(t *testing.T) {
+	a := newAdminState()
+	a.recordProvider(&fakeProvider{name: "zeta"}, true, 1, 0)
+	a.recordProvider(&fakeProvider{name: "alpha"}, false, 2, 0)
+
+	got := a.providerStatuses()
+	if len(got) != 2 || got[0].Name != "alpha" || got[1].Name != "zeta" {
+		t.Fatalf("providerStatuses = %+v, want alpha before zeta", got)
+	}
+}
+
+func TestAdminStateRecordProviderIsNilSafe(t *testing.T) {
+	var a *AdminState
+	a.recordProvider(&fakeProvider{name: "alpha"}, true, 1, 0)
+	a.recordStage("disks", true)
+}
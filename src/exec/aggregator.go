@@ -0,0 +1,182 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/ignition/src/metrics"
+	"github.com/coreos/ignition/src/providers"
+)
+
+// settleWindow bounds how long aggregateProviders waits, once the first
+// provider comes online, for additional providers to also come online
+// before it commits to a result. This is what lets a high-priority but
+// slow-to-initialize provider (e.g. one waiting on DHCP) still win over a
+// fast low-priority one that happened to answer first.
+const settleWindow = 2 * time.Second
+
+// aggregateProviders races the given providers to come online exactly as
+// a single-winner selectProvider historically did, but:
+//
+//   - a provider that implements providers.Throttler has its IsOnline
+//     result re-checked after ThrottleDuration before being trusted, to
+//     coalesce rapid online/offline flapping;
+//   - once at least one provider is online, it waits up to settleWindow
+//     for others to catch up instead of returning immediately;
+//   - it returns every provider that was online by the end of that
+//     window, ordered from lowest to highest providers.Prioritized
+//     priority (unprioritized providers default to 0), so callers can
+//     merge their configs with later entries overriding earlier ones.
+//
+// admin, if non-nil, is updated with each provider's Name, last IsOnline
+// result, last error (for providers.ErrorReporter implementations),
+// attempt count, and cumulative backoff, so the admin HTTP server can
+// explain why a provider hasn't come online yet. It may be nil, e.g. from
+// code that doesn't go through Engine.Init.
+func aggregateProviders(ps []providers.Provider, timeout time.Duration, admin *AdminState) ([]providers.Provider, error) {
+	online := make(chan providers.Provider, len(ps))
+	wg := sync.WaitGroup{}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	start := time.Now()
+
+	for _, p := range ps {
+		wg.Add(1)
+		go func(provider providers.Provider) {
+			defer wg.Done()
+
+			var attempts int
+			var cumulativeBackoff time.Duration
+
+			for {
+				isOnline := provider.IsOnline()
+				admin.recordProvider(provider, isOnline, attempts, cumulativeBackoff)
+
+				if isOnline && settled(provider, stop) {
+					providerOnlineSeconds.Observe(metrics.Labels("provider", provider.Name()), time.Since(start).Seconds())
+					online <- provider
+					return
+				} else if !provider.ShouldRetry() {
+					return
+				}
+
+				backoff := provider.BackoffDuration()
+				attempts++
+				cumulativeBackoff += backoff
+				providerBackoffSeconds.Set(metrics.Labels("provider", provider.Name()), backoff.Seconds())
+
+				select {
+				case <-time.After(backoff):
+				case <-stop:
+					return
+				}
+			}
+		}(p)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var found []providers.Provider
+	select {
+	case p := <-online:
+		found = append(found, p)
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	case <-done:
+		// A provider sends itself to the buffered online channel before
+		// calling wg.Done, so its value and the close of done can both
+		// be ready at once; done winning this pseudo-random select must
+		// not be read as "no provider ever came online" without checking
+		// for one first.
+		select {
+		case p := <-online:
+			found = append(found, p)
+		default:
+			return nil, ErrNoProviders
+		}
+	}
+
+	settle := time.After(settleWindow)
+collect:
+	for {
+		select {
+		case p := <-online:
+			found = append(found, p)
+		case <-done:
+			break collect
+		case <-settle:
+			break collect
+		}
+	}
+
+	// done and settle can both be ready at the same time as a value
+	// already sitting in the buffered online channel (every goroutine
+	// sends before calling wg.Done, and select among ready cases is
+	// pseudo-random), so done or settle winning above must not be taken
+	// to mean online is empty. Drain whatever's left non-blockingly
+	// before committing to found.
+drain:
+	for {
+		select {
+		case p := <-online:
+			found = append(found, p)
+		default:
+			break drain
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return priorityOf(found[i]) < priorityOf(found[j])
+	})
+	return found, nil
+}
+
+// settled re-checks a provider that just reported online after its
+// ThrottleDuration, returning false if it flapped back offline in the
+// meantime. Providers that don't implement providers.Throttler are
+// trusted immediately.
+func settled(provider providers.Provider, stop <-chan struct{}) bool {
+	t, ok := provider.(providers.Throttler)
+	if !ok {
+		return true
+	}
+
+	d := t.ThrottleDuration()
+	if d <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(d):
+	case <-stop:
+		return false
+	}
+	return provider.IsOnline()
+}
+
+func priorityOf(provider providers.Provider) int {
+	if p, ok := provider.(providers.Prioritized); ok {
+		return p.Priority()
+	}
+	return 0
+}
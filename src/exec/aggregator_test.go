@@ -0,0 +1,155 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/src/providers"
+)
+
+// fakeProvider is a minimal providers.Provider whose online-ness and
+// priority are fixed at construction, for exercising aggregateProviders
+// without a real provider's network I/O.
+type fakeProvider struct {
+	name     string
+	online   bool
+	priority int
+	throttle time.Duration
+
+	polls int32
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) IsOnline() bool {
+	atomic.AddInt32(&p.polls, 1)
+	return p.online
+}
+
+func (p *fakeProvider) ShouldRetry() bool { return false }
+
+func (p *fakeProvider) BackoffDuration() time.Duration { return time.Millisecond }
+
+func (p *fakeProvider) FetchConfig() (config.Config, error) { return config.Config{}, config.ErrEmpty }
+
+func (p *fakeProvider) Priority() int { return p.priority }
+
+func (p *fakeProvider) ThrottleDuration() time.Duration { return p.throttle }
+
+var (
+	_ providers.Provider    = (*fakeProvider)(nil)
+	_ providers.Prioritized = (*fakeProvider)(nil)
+	_ providers.Throttler   = (*fakeProvider)(nil)
+)
+
+func TestAggregateProvidersNoneOnline(t *testing.T) {
+	ps := []providers.Provider{
+		&fakeProvider{name: "a"},
+		&fakeProvider{name: "b"},
+	}
+
+	_, err := aggregateProviders(ps, time.Second, nil)
+	if err != ErrNoProviders {
+		t.Fatalf("err = %v, want ErrNoProviders", err)
+	}
+}
+
+func TestAggregateProvidersTimeout(t *testing.T) {
+	// ShouldRetry is false, so a never-online provider exits its polling
+	// goroutine immediately rather than looping until timeout; use a
+	// retrying provider instead so aggregateProviders actually has to
+	// wait out the timeout.
+	ps := []providers.Provider{&retryingProvider{}}
+
+	_, err := aggregateProviders(ps, 10*time.Millisecond, nil)
+	if err != ErrTimeout {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
+
+// retryingProvider is never online and always asks to be retried, so it
+// keeps aggregateProviders waiting until the caller's timeout fires.
+type retryingProvider struct{}
+
+func (retryingProvider) Name() string                        { return "retrying" }
+func (retryingProvider) IsOnline() bool                      { return false }
+func (retryingProvider) ShouldRetry() bool                   { return true }
+func (retryingProvider) BackoffDuration() time.Duration      { return time.Millisecond }
+func (retryingProvider) FetchConfig() (config.Config, error) { return config.Config{}, config.ErrEmpty }
+
+func TestAggregateProvidersPriorityOrder(t *testing.T) {
+	low := &fakeProvider{name: "low", online: true, priority: 0}
+	high := &fakeProvider{name: "high", online: true, priority: 10}
+
+	found, err := aggregateProviders([]providers.Provider{low, high}, time.Second, nil)
+	if err != nil {
+		t.Fatalf("aggregateProviders: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("len(found) = %d, want 2", len(found))
+	}
+	if found[0].Name() != "low" || found[1].Name() != "high" {
+		t.Fatalf("found = %v, want [low, high] (lowest priority first)", names(found))
+	}
+}
+
+func TestAggregateProvidersThrottleFlapExcluded(t *testing.T) {
+	// flapper reports online once, then goes offline by the time
+	// settled() re-checks it after ThrottleDuration, so it should never
+	// appear in the result.
+	flapper := &flappingProvider{throttle: 5 * time.Millisecond}
+	stable := &fakeProvider{name: "stable", online: true}
+
+	found, err := aggregateProviders([]providers.Provider{flapper, stable}, time.Second, nil)
+	if err != nil {
+		t.Fatalf("aggregateProviders: %v", err)
+	}
+	if len(found) != 1 || found[0].Name() != "stable" {
+		t.Fatalf("found = %v, want [stable] (flapper should be excluded)", names(found))
+	}
+}
+
+// flappingProvider reports online on its first IsOnline call and offline
+// on every call after, simulating a provider whose answer changes out
+// from under settled()'s re-check.
+type flappingProvider struct {
+	throttle time.Duration
+	calls    int32
+}
+
+func (p *flappingProvider) Name() string { return "flapper" }
+
+func (p *flappingProvider) IsOnline() bool {
+	return atomic.AddInt32(&p.calls, 1) == 1
+}
+
+func (p *flappingProvider) ShouldRetry() bool              { return false }
+func (p *flappingProvider) BackoffDuration() time.Duration { return time.Millisecond }
+func (p *flappingProvider) FetchConfig() (config.Config, error) {
+	return config.Config{}, config.ErrEmpty
+}
+func (p *flappingProvider) ThrottleDuration() time.Duration { return p.throttle }
+
+func names(ps []providers.Provider) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.Name()
+	}
+	return out
+}
@@ -15,21 +15,27 @@
 package exec
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"sync"
 	"time"
 
 	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/src/cache"
 	"github.com/coreos/ignition/src/exec/stages"
 	"github.com/coreos/ignition/src/log"
+	"github.com/coreos/ignition/src/metrics"
 	"github.com/coreos/ignition/src/providers"
+	"github.com/coreos/ignition/src/providers/remote"
+	"github.com/coreos/ignition/src/providers/util"
 	"github.com/coreos/ignition/src/registry"
 )
 
 const (
 	DefaultFetchTimeout = time.Minute
+
+	// cmdlineConfigURL is the same kernel cmdline option the remote
+	// provider reads; fetchConfigURL reads it directly so it can chain
+	// to remote.Fetch without a registered provider.
+	cmdlineConfigURL = "coreos.config.url"
 )
 
 var (
@@ -39,15 +45,41 @@ var (
 
 // Engine represents the entity that fetches and executes a configuration.
 type Engine struct {
-	ConfigCache  string
+	ConfigCache  cache.ConfigStore
 	FetchTimeout time.Duration
 	Logger       log.Logger
 	Root         string
-	providers    *registry.Registry
+
+	// AdminAddress, if non-empty, is the address the optional admin HTTP
+	// server (see ServeAdmin) listens on. It's opt-in: with no address
+	// set, no listener is started. It exists for debugging first-boot
+	// failures without SSH. The same server also exposes the Prometheus
+	// metrics described in src/metrics at /metrics.
+	AdminAddress string
+
+	// MetricsTextfilePath, if non-empty, is written after every stage run
+	// with the current metrics in node_exporter's textfile collector
+	// format, for fleets that scrape node_exporter rather than Ignition
+	// itself (which, outside of first boot, isn't a long-lived process to
+	// scrape).
+	MetricsTextfilePath string
+
+	providers *registry.Registry
+	admin     *AdminState
 }
 
 func (e Engine) Init() Engine {
 	e.providers = registry.Create("engine.providers")
+	e.admin = newAdminState()
+
+	if e.AdminAddress != "" {
+		go func() {
+			if err := e.ServeAdmin(); err != nil {
+				e.Logger.Crit("admin server exited: %v", err)
+			}
+		}()
+	}
+
 	return e
 }
 
@@ -79,7 +111,18 @@ func (e Engine) Run(stageName string) bool {
 	case nil:
 		e.Logger.PushPrefix(stageName)
 		defer e.Logger.PopPrefix()
-		return stages.Get(stageName).Create(&e.Logger, e.Root).Run(cfg)
+
+		start := time.Now()
+		ok := stages.Get(stageName).Create(&e.Logger, e.Root).Run(cfg)
+		stageDurationSeconds.Observe(metrics.Labels("stage", stageName), time.Since(start).Seconds())
+		e.admin.recordStage(stageName, ok)
+
+		if e.MetricsTextfilePath != "" {
+			if err := metrics.WriteTextfile(e.MetricsTextfilePath); err != nil {
+				e.Logger.Crit("writing metrics textfile: %v", err)
+			}
+		}
+		return ok
 	case config.ErrCloudConfig, config.ErrScript, config.ErrEmpty:
 		e.Logger.Info("%v: ignoring and exiting...", err)
 		return true
@@ -89,20 +132,22 @@ func (e Engine) Run(stageName string) bool {
 	}
 }
 
-// acquireConfig returns the configuration, first checking a local cache
+// acquireConfig returns the configuration, first checking the config cache
 // before attempting to fetch it from the registered providers.
 func (e Engine) acquireConfig() (cfg config.Config, err error) {
-	// First try read the config @ e.ConfigCache.
-	b, err := ioutil.ReadFile(e.ConfigCache)
-	if err == nil {
-		if err = json.Unmarshal(b, &cfg); err != nil {
-			e.Logger.Crit("failed to parse cached config: %v", err)
-		}
+	// First try to load the config from e.ConfigCache.
+	if cfg, err = e.ConfigCache.Load(); err == nil {
 		return
 	}
+	if err != cache.ErrNotCached {
+		// Something was cached but couldn't be used, e.g. on-disk
+		// corruption; surface that instead of silently falling through
+		// to a re-fetch as if nothing had ever been saved.
+		e.Logger.Crit("failed to load cached config, re-fetching: %v", err)
+	}
 
-	// (Re)Fetch the config if the cache is unreadable.
-	cfg, err = fetchConfig(e.Providers(), e.FetchTimeout)
+	// (Re)Fetch the config if the cache is empty or unreadable.
+	cfg, err = fetchConfig(e.Providers(), e.FetchTimeout, e.admin)
 	if err != nil {
 		e.Logger.Crit("failed to fetch config: %v", err)
 		return
@@ -110,74 +155,91 @@ func (e Engine) acquireConfig() (cfg config.Config, err error) {
 	e.Logger.Debug("fetched config: %+v", cfg)
 
 	// Populate the config cache.
-	b, err = json.Marshal(cfg)
-	if err != nil {
-		e.Logger.Crit("failed to marshal cached config: %v", err)
-		return
-	}
-	if err = ioutil.WriteFile(e.ConfigCache, b, 0640); err != nil {
-		e.Logger.Crit("failed to write cached config: %v", err)
+	if err = e.ConfigCache.Save(cfg); err != nil {
+		e.Logger.Crit("failed to cache config: %v", err)
 		return
 	}
 
 	return
 }
 
-// fetchConfig returns the configuration from the first available provider or
-// returns an error if none of the providers are available.
-func fetchConfig(providers []providers.Provider, timeout time.Duration) (config.Config, error) {
-	if provider, err := selectProvider(providers, timeout); err == nil {
-		return provider.FetchConfig()
-	} else {
+// fetchConfig races the registered providers to come online (see
+// aggregateProviders), authenticates and fetches from each one that did,
+// and merges their configs together in priority order: a provider with a
+// higher Priority overrides one with a lower Priority wherever they both
+// set the same field. A provider with no opinion (config.ErrEmpty) simply
+// doesn't contribute to the merge.
+func fetchConfig(ps []providers.Provider, timeout time.Duration, admin *AdminState) (config.Config, error) {
+	online, err := aggregateProviders(ps, timeout, admin)
+	if err == ErrNoProviders {
+		// No registered provider ever came online. Some platforms have
+		// no Ignition-specific metadata service of their own and expect
+		// coreos.config.url to be used directly rather than growing a
+		// dedicated provider just to poll it once; chain to the same
+		// remote.Fetch a cloud provider would use for a URL-pointer
+		// userdata instead of requiring every such platform to duplicate
+		// this provider's fetch logic.
+		if cfg, fetchErr := fetchConfigURL(); fetchErr == nil {
+			return cfg, nil
+		}
+		return config.Config{}, err
+	}
+	if err != nil {
 		return config.Config{}, err
 	}
-}
 
-// selectProvider chooses the first online provider, given a list of providers
-// and a timeout. If none of the providers will ever be online, or if the
-// timeout elapses before any providers are online, this returns an appropriate
-// error.
-func selectProvider(ps []providers.Provider, timeout time.Duration) (providers.Provider, error) {
-	online := make(chan providers.Provider)
-	wg := sync.WaitGroup{}
-	stop := make(chan struct{})
-	defer close(stop)
-
-	for _, p := range ps {
-		wg.Add(1)
-		go func(provider providers.Provider) {
-			defer wg.Done()
-
-			for {
-				if provider.IsOnline() {
-					online <- provider
-					return
-				} else if !provider.ShouldRetry() {
-					return
-				}
-
-				select {
-				case <-time.After(provider.BackoffDuration()):
-				case <-stop:
-					return
-				}
+	var merged config.Config
+	haveConfig := false
+	for _, provider := range online {
+		if af, ok := provider.(providers.AuthenticatedFetcher); ok {
+			if err := af.Authenticate(); err != nil {
+				return config.Config{}, err
 			}
-		}(p)
+		}
+
+		cfg, err := provider.FetchConfig()
+
+		result := "error"
+		switch err {
+		case nil:
+			result = "success"
+		case config.ErrEmpty:
+			result = "empty"
+		}
+		providerFetchTotal.Inc(metrics.Labels("provider", provider.Name(), "result", result))
+
+		switch err {
+		case nil:
+		case config.ErrEmpty:
+			continue
+		default:
+			return config.Config{}, err
+		}
+
+		if !haveConfig {
+			merged = cfg
+			haveConfig = true
+			continue
+		}
+
+		if merged, err = mergeConfigs(merged, cfg); err != nil {
+			return config.Config{}, err
+		}
+	}
+
+	if !haveConfig {
+		return config.Config{}, config.ErrEmpty
 	}
+	return merged, nil
+}
 
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-	var provider providers.Provider
-	select {
-	case provider = <-online:
-		return provider, nil
-	case <-done:
-		return nil, ErrNoProviders
-	case <-time.After(timeout):
-		return nil, ErrTimeout
+// fetchConfigURL resolves coreos.config.url directly via remote.Fetch. It
+// returns an error (without wrapping or logging it) whenever the option
+// isn't set, so callers can fall through to their own error.
+func fetchConfigURL() (config.Config, error) {
+	raw, ok := util.Getopt(cmdlineConfigURL)
+	if !ok || raw == "" {
+		return config.Config{}, ErrNoProviders
 	}
+	return remote.Fetch(raw, nil)
 }
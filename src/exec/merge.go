@@ -0,0 +1,89 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+
+	"github.com/coreos/ignition/config"
+)
+
+// mergeConfigs deep-merges override on top of base and returns the result.
+// It round-trips both through JSON rather than walking config.Config's
+// fields directly, so that merging keeps working as the config spec grows
+// new fields. Object keys present in override replace or recursively merge
+// with those in base; non-object values, including arrays, are replaced
+// outright rather than concatenated.
+func mergeConfigs(base, override config.Config) (config.Config, error) {
+	baseMap, err := toMap(base)
+	if err != nil {
+		return config.Config{}, err
+	}
+	overrideMap, err := toMap(override)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	merged := mergeMaps(baseMap, overrideMap)
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return config.Config{}, err
+	}
+	return cfg, nil
+}
+
+func toMap(cfg config.Config) (map[string]interface{}, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, overrideVal := range override {
+		baseVal, ok := merged[k]
+		if !ok {
+			merged[k] = overrideVal
+			continue
+		}
+
+		baseObj, baseIsObj := baseVal.(map[string]interface{})
+		overrideObj, overrideIsObj := overrideVal.(map[string]interface{})
+		if baseIsObj && overrideIsObj {
+			merged[k] = mergeMaps(baseObj, overrideObj)
+		} else {
+			merged[k] = overrideVal
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/config"
+)
+
+func TestMergeMapsOverrideWins(t *testing.T) {
+	base := map[string]interface{}{"a": "base", "b": "base-only"}
+	override := map[string]interface{}{"a": "override"}
+
+	got := mergeMaps(base, override)
+	want := map[string]interface{}{"a": "override", "b": "base-only"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeMaps = %v, want %v", got, want)
+	}
+}
+
+func TestMergeMapsRecursesIntoNestedObjects(t *testing.T) {
+	base := map[string]interface{}{
+		"ignition": map[string]interface{}{"version": "2.0.0", "config": "base"},
+	}
+	override := map[string]interface{}{
+		"ignition": map[string]interface{}{"version": "2.1.0"},
+	}
+
+	got := mergeMaps(base, override)
+	want := map[string]interface{}{
+		"ignition": map[string]interface{}{"version": "2.1.0", "config": "base"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeMaps = %v, want %v", got, want)
+	}
+}
+
+func TestMergeMapsArraysReplacedNotConcatenated(t *testing.T) {
+	base := map[string]interface{}{"files": []interface{}{"a", "b"}}
+	override := map[string]interface{}{"files": []interface{}{"c"}}
+
+	got := mergeMaps(base, override)
+	want := map[string]interface{}{"files": []interface{}{"c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("mergeMaps = %v, want %v (arrays should be replaced outright)", got, want)
+	}
+}
+
+func TestMergeMapsDoesNotMutateInputs(t *testing.T) {
+	base := map[string]interface{}{"a": "base"}
+	override := map[string]interface{}{"a": "override"}
+
+	mergeMaps(base, override)
+
+	if base["a"] != "base" {
+		t.Fatalf("base[\"a\"] = %v, want unchanged \"base\"", base["a"])
+	}
+	if override["a"] != "override" {
+		t.Fatalf("override[\"a\"] = %v, want unchanged \"override\"", override["a"])
+	}
+}
+
+func TestMergeConfigsEmpty(t *testing.T) {
+	merged, err := mergeConfigs(config.Config{}, config.Config{})
+	if err != nil {
+		t.Fatalf("mergeConfigs: %v", err)
+	}
+	if !reflect.DeepEqual(merged, config.Config{}) {
+		t.Fatalf("mergeConfigs(Config{}, Config{}) = %+v, want the zero value", merged)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import "github.com/coreos/ignition/src/metrics"
+
+// The metrics the engine reports, for operators tracking boot-time
+// provisioning fleet-wide. See src/metrics for the exposition mechanics.
+var (
+	providerFetchTotal = metrics.NewCounter(
+		"ignition_provider_fetch_total",
+		"Total number of provider FetchConfig attempts, by result.",
+		"provider", "result",
+	)
+
+	providerOnlineSeconds = metrics.NewHistogram(
+		"ignition_provider_online_seconds",
+		"Time from the start of provider aggregation until a provider reported online.",
+		[]float64{0.1, 0.5, 1, 2, 5, 10, 30, 60},
+		"provider",
+	)
+
+	stageDurationSeconds = metrics.NewHistogram(
+		"ignition_stage_duration_seconds",
+		"Time taken to run a stage.",
+		[]float64{0.1, 0.5, 1, 5, 10, 30, 60, 300},
+		"stage",
+	)
+
+	providerBackoffSeconds = metrics.NewGauge(
+		"ignition_provider_backoff_seconds",
+		"Most recently computed BackoffDuration for a provider still being polled.",
+		"provider",
+	)
+)
@@ -0,0 +1,89 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// alwaysSensitive lists JSON field names (matched case-insensitively) that
+// are masked wherever they appear in a config, regardless of context, such
+// as a user's passwordHash or a TLS private key.
+var alwaysSensitive = map[string]bool{
+	"passwordhash": true,
+	"privatekey":   true,
+	"key":          true,
+}
+
+// redact returns cfg's JSON representation as a generic tree with
+// passwordHash, TLS private keys, and the Contents of any systemd unit
+// marked Sensitive replaced with redacted, for safe display by the admin
+// endpoint. It works off the JSON form rather than config.Config's Go
+// types directly so it doesn't need to track every field the config
+// schema grows; the caller only needs something safe to print.
+func redact(cfg interface{}) interface{} {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+
+	return redactTree(tree)
+}
+
+func redactTree(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return redactObject(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, e := range val {
+			out[i] = redactTree(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func redactObject(m map[string]interface{}) map[string]interface{} {
+	// A systemd unit is represented as an object with its own Sensitive
+	// field; when set, its Contents are masked alongside the fields that
+	// are always sensitive.
+	unitIsSensitive, _ := m["sensitive"].(bool)
+
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		name := strings.ToLower(k)
+		switch {
+		case v == nil:
+			out[k] = v
+		case alwaysSensitive[name]:
+			out[k] = redacted
+		case unitIsSensitive && name == "contents":
+			out[k] = redacted
+		default:
+			out[k] = redactTree(v)
+		}
+	}
+	return out
+}
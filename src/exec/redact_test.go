@@ -0,0 +1,97 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactAlwaysSensitiveFields(t *testing.T) {
+	in := map[string]interface{}{
+		"PasswordHash": "hunter2",
+		"PrivateKey":   "-----BEGIN KEY-----",
+		"Key":          "-----BEGIN KEY-----",
+		"Name":         "core",
+	}
+	want := map[string]interface{}{
+		"PasswordHash": redacted,
+		"PrivateKey":   redacted,
+		"Key":          redacted,
+		"Name":         "core",
+	}
+
+	got := redact(in)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("redact(%+v) = %+v, want %+v", in, got, want)
+	}
+}
+
+func TestRedactUnitContentsOnlyWhenSensitive(t *testing.T) {
+	in := map[string]interface{}{
+		"units": []interface{}{
+			map[string]interface{}{
+				"name":      "secret.service",
+				"sensitive": true,
+				"contents":  "super secret unit file",
+			},
+			map[string]interface{}{
+				"name":     "public.service",
+				"contents": "perfectly fine to show",
+			},
+		},
+	}
+
+	got := redact(in)
+
+	units, ok := got.(map[string]interface{})["units"].([]interface{})
+	if !ok || len(units) != 2 {
+		t.Fatalf("redact(%+v) = %+v, want a 2-element units slice", in, got)
+	}
+
+	sensitive := units[0].(map[string]interface{})
+	if sensitive["contents"] != redacted {
+		t.Fatalf("sensitive unit's contents = %v, want %q", sensitive["contents"], redacted)
+	}
+
+	public := units[1].(map[string]interface{})
+	if public["contents"] != "perfectly fine to show" {
+		t.Fatalf("non-sensitive unit's contents = %v, want it left untouched", public["contents"])
+	}
+}
+
+func TestRedactNestedTree(t *testing.T) {
+	in := map[string]interface{}{
+		"storage": map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{
+					"name":         "core",
+					"passwordHash": "hunter2",
+				},
+			},
+		},
+	}
+
+	got := redact(in)
+
+	users := got.(map[string]interface{})["storage"].(map[string]interface{})["users"].([]interface{})
+	user := users[0].(map[string]interface{})
+	if user["passwordHash"] != redacted {
+		t.Fatalf("nested passwordHash = %v, want %q", user["passwordHash"], redacted)
+	}
+	if user["name"] != "core" {
+		t.Fatalf("nested name = %v, want it left untouched", user["name"])
+	}
+}
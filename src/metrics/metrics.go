@@ -0,0 +1,284 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics gives operators boot-time observability into the
+// engine: a handful of counters, histograms and gauges rendered in the
+// Prometheus text exposition format, served from the admin HTTP server's
+// /metrics endpoint or written to a file for node_exporter's textfile
+// collector. Ignition only ever exposes a fixed, small set of series, so
+// this implements just enough of the exposition format by hand rather
+// than pulling in the full client_golang library.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels formats label name/value pairs, given as alternating name, value
+// strings, into the "name=\"value\",..." form used both as this package's
+// internal per-series key and directly in exposition output. Call it with
+// no arguments for a series with no labels.
+func Labels(pairs ...string) string {
+	if len(pairs)%2 != 0 {
+		panic("metrics: Labels called with an odd number of arguments")
+	}
+
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+	}
+	return strings.Join(parts, ",")
+}
+
+type metric interface {
+	write(*bytes.Buffer)
+}
+
+// registry holds every Counter, Histogram and Gauge created via this
+// package, in the order they were created, so that WriteTo produces
+// stable output.
+type registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func (r *registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	ms := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range ms {
+		m.write(&buf)
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+var defaultRegistry = &registry{}
+
+// Handler returns an http.Handler that serves every registered metric in
+// the Prometheus text exposition format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		defaultRegistry.WriteTo(w)
+	})
+}
+
+// WriteTextfile renders every registered metric and writes it to path,
+// atomically, in the layout node_exporter's textfile collector expects.
+func WriteTextfile(path string) error {
+	var buf bytes.Buffer
+	if _, err := defaultRegistry.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeSeries(buf *bytes.Buffer, name, labels string, value float64) {
+	if labels == "" {
+		fmt.Fprintf(buf, "%s %v\n", name, value)
+		return
+	}
+	fmt.Fprintf(buf, "%s{%s} %v\n", name, labels, value)
+}
+
+func writeHeader(buf *bytes.Buffer, name, help, typ string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, typ)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a monotonically increasing value, broken down by label.
+type Counter struct {
+	name, help string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates and registers a Counter. labelNames is purely
+// documentation here (the exposition format doesn't need it); the actual
+// label set for a given series comes from the Labels string passed to
+// Inc.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{name: name, help: help, values: map[string]float64{}}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc increments the series identified by labels (see Labels) by one.
+func (c *Counter) Inc(labels string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labels]++
+}
+
+func (c *Counter) write(buf *bytes.Buffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(buf, c.name, c.help, "counter")
+	for _, labels := range sortedKeys(c.values) {
+		writeSeries(buf, c.name, labels, c.values[labels])
+	}
+}
+
+// Gauge is a value that can go up or down, broken down by label.
+type Gauge struct {
+	name, help string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates and registers a Gauge.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{name: name, help: help, values: map[string]float64{}}
+	defaultRegistry.register(g)
+	return g
+}
+
+// Set records the current value of the series identified by labels.
+func (g *Gauge) Set(labels string, value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[labels] = value
+}
+
+func (g *Gauge) write(buf *bytes.Buffer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	writeHeader(buf, g.name, g.help, "gauge")
+	for _, labels := range sortedKeys(g.values) {
+		writeSeries(buf, g.name, labels, g.values[labels])
+	}
+}
+
+// Histogram buckets observed values by their upper bound, per label.
+type Histogram struct {
+	name, help string
+	bounds     []float64 // ascending, exclusive of the implicit +Inf bucket
+
+	mu   sync.Mutex
+	bins map[string][]uint64 // labels -> per-bucket (non-cumulative) counts
+	sums map[string]float64
+}
+
+// NewHistogram creates and registers a Histogram with the given bucket
+// upper bounds, which must be sorted ascending.
+func NewHistogram(name, help string, bounds []float64, labelNames ...string) *Histogram {
+	h := &Histogram{
+		name:   name,
+		help:   help,
+		bounds: bounds,
+		bins:   map[string][]uint64{},
+		sums:   map[string]float64{},
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe records value in the series identified by labels.
+func (h *Histogram) Observe(labels string, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bins, ok := h.bins[labels]
+	if !ok {
+		bins = make([]uint64, len(h.bounds)+1)
+		h.bins[labels] = bins
+	}
+
+	// SearchFloat64s returns the smallest index whose bound is >= value,
+	// i.e. the first bucket this observation falls into; len(h.bounds)
+	// itself means it overflowed every bound into the +Inf bucket.
+	i := sort.SearchFloat64s(h.bounds, value)
+	bins[i]++
+	h.sums[labels] += value
+}
+
+func (h *Histogram) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(buf, h.name, h.help, "histogram")
+
+	keys := make([]string, 0, len(h.bins))
+	for k := range h.bins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		bins := h.bins[labels]
+
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += bins[i]
+			fmt.Fprintf(buf, "%s_bucket{%sle=%q} %d\n", h.name, withComma(labels), formatBound(bound), cumulative)
+		}
+		cumulative += bins[len(h.bounds)]
+		fmt.Fprintf(buf, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, withComma(labels), cumulative)
+		// _sum/_count go through writeSeries, same as Counter/Gauge, so a
+		// label-less histogram renders "name_sum 5" rather than the
+		// invalid "name_sum{} 5" that interpolating labels directly here
+		// would produce.
+		writeSeries(buf, h.name+"_sum", labels, h.sums[labels])
+		writeSeries(buf, h.name+"_count", labels, float64(cumulative))
+	}
+}
+
+// withComma appends a trailing comma to labels so a "le" bucket bound can
+// be tacked directly onto an existing label set, or returns "" unchanged
+// when there are no other labels.
+func withComma(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return labels + ","
+}
+
+func formatBound(f float64) string {
+	return fmt.Sprintf("%g", f)
+}
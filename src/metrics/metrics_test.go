@@ -0,0 +1,121 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterWrite(t *testing.T) {
+	c := &Counter{name: "test_counter_total", help: "a test counter", values: map[string]float64{}}
+	c.Inc(Labels("result", "success"))
+	c.Inc(Labels("result", "success"))
+	c.Inc(Labels("result", "error"))
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	got := buf.String()
+
+	want := `# HELP test_counter_total a test counter
+# TYPE test_counter_total counter
+test_counter_total{result="error"} 1
+test_counter_total{result="success"} 2
+`
+	if got != want {
+		t.Fatalf("write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGaugeWrite(t *testing.T) {
+	g := &Gauge{name: "test_gauge", help: "a test gauge", values: map[string]float64{}}
+	g.Set("", 42)
+
+	var buf bytes.Buffer
+	g.write(&buf)
+	got := buf.String()
+
+	want := `# HELP test_gauge a test gauge
+# TYPE test_gauge gauge
+test_gauge 42
+`
+	if got != want {
+		t.Fatalf("write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	h := &Histogram{
+		name:   "test_histogram_seconds",
+		help:   "a test histogram",
+		bounds: []float64{0.1, 1, 10},
+		bins:   map[string][]uint64{},
+		sums:   map[string]float64{},
+	}
+	h.Observe(Labels("stage", "disks"), 0.05) // bucket 0.1
+	h.Observe(Labels("stage", "disks"), 5)    // bucket 10
+	h.Observe(Labels("stage", "disks"), 50)   // +Inf
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	got := buf.String()
+
+	want := `# HELP test_histogram_seconds a test histogram
+# TYPE test_histogram_seconds histogram
+test_histogram_seconds_bucket{stage="disks",le="0.1"} 1
+test_histogram_seconds_bucket{stage="disks",le="1"} 1
+test_histogram_seconds_bucket{stage="disks",le="10"} 2
+test_histogram_seconds_bucket{stage="disks",le="+Inf"} 3
+test_histogram_seconds_sum{stage="disks"} 55.05
+test_histogram_seconds_count{stage="disks"} 3
+`
+	if got != want {
+		t.Fatalf("write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestHistogramWriteNoLabels is a regression test: the _sum/_count lines
+// used to interpolate labels directly rather than going through
+// writeSeries like Counter and Gauge do, so a label-less histogram
+// rendered the invalid "name_sum{} 5" instead of omitting the braces.
+func TestHistogramWriteNoLabels(t *testing.T) {
+	h := &Histogram{
+		name:   "test_histogram_seconds",
+		help:   "a test histogram",
+		bounds: []float64{1},
+		bins:   map[string][]uint64{},
+		sums:   map[string]float64{},
+	}
+	h.Observe("", 0.5)
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	got := buf.String()
+
+	if strings.Contains(got, "_sum{}") || strings.Contains(got, "_count{}") {
+		t.Fatalf("write() produced an empty label set with braces:\n%s", got)
+	}
+	want := `# HELP test_histogram_seconds a test histogram
+# TYPE test_histogram_seconds histogram
+test_histogram_seconds_bucket{le="1"} 1
+test_histogram_seconds_bucket{le="+Inf"} 1
+test_histogram_seconds_sum 0.5
+test_histogram_seconds_count 1
+`
+	if got != want {
+		t.Fatalf("write() =\n%s\nwant\n%s", got, want)
+	}
+}
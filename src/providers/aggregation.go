@@ -0,0 +1,47 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import "time"
+
+// Throttler is implemented by providers whose IsOnline result can flap,
+// for example a network metadata service that answers briefly before the
+// network interface is fully configured. The engine re-checks IsOnline
+// after ThrottleDuration before trusting an online result, coalescing the
+// flap instead of racing ahead with a result that's about to change.
+type Throttler interface {
+	Provider
+	ThrottleDuration() time.Duration
+}
+
+// Prioritized is implemented by providers that should take precedence
+// over others when more than one comes online at the same time, such as a
+// local override file meant to win over a cloud metadata service. Higher
+// values win; a provider that doesn't implement Prioritized is treated as
+// priority 0.
+type Prioritized interface {
+	Provider
+	Priority() int
+}
+
+// ErrorReporter is implemented by providers that can surface the error
+// from their most recent IsOnline or FetchConfig attempt. It's optional
+// and exists so that observability code, such as the engine's admin
+// endpoint, can explain why a provider hasn't come online yet; a provider
+// that doesn't implement it is simply reported with no error.
+type ErrorReporter interface {
+	Provider
+	LastError() error
+}
@@ -0,0 +1,212 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/ignition/src/providers/util"
+)
+
+// Kernel cmdline options used to configure a Client. All but the issuer are
+// optional, since a provider may instead rely on the jwt-bearer grant with
+// credentials supplied some other way (e.g. a platform-signed assertion).
+const (
+	cmdlineIssuer       = "coreos.config.auth.issuer"
+	cmdlineClientID     = "coreos.config.auth.client_id"
+	cmdlineClientSecret = "coreos.config.auth.client_secret"
+	cmdlineAudience     = "coreos.config.auth.audience"
+)
+
+// Token is a bearer token obtained from the issuer's token endpoint.
+type Token struct {
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// Expired reports whether the token is expired or about to expire.
+func (t *Token) Expired() bool {
+	return t == nil || time.Now().Add(5*time.Second).After(t.ExpiresAt)
+}
+
+// Client obtains and caches bearer tokens for a single OIDC issuer. It is
+// safe for concurrent use.
+type Client struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Audience     string
+	HTTPClient   *http.Client
+
+	mu                   sync.Mutex
+	doc                  *discovery
+	token                *Token
+	lastDiscoveryAttempt time.Time
+}
+
+// NewClientFromCmdline builds a Client from the coreos.config.auth.*
+// kernel parameters. It returns nil, false if no issuer is configured,
+// meaning authentication was not requested at all.
+func NewClientFromCmdline() (*Client, bool) {
+	issuer, ok := util.Getopt(cmdlineIssuer)
+	if !ok || issuer == "" {
+		return nil, false
+	}
+
+	clientID, _ := util.Getopt(cmdlineClientID)
+	clientSecret, _ := util.Getopt(cmdlineClientSecret)
+	audience, _ := util.Getopt(cmdlineAudience)
+
+	return &Client{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Audience:     audience,
+	}, true
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// discoveryRetryAllowed reports whether enough time has passed since the
+// last discovery fetch attempt (successful or not) to try again. It rate
+// limits retries after a failed fetch; a successful one is instead gated
+// by discovery.stale's maxDiscoveryInterval.
+func (c *Client) discoveryRetryAllowed() bool {
+	return c.lastDiscoveryAttempt.IsZero() || time.Since(c.lastDiscoveryAttempt) >= minDiscoveryInterval
+}
+
+// Token returns a valid bearer token, re-using the cached one if it hasn't
+// expired and re-running discovery only as often as discovery.stale()
+// allows.
+func (c *Client) Token() (*Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.token.Expired() {
+		return c.token, nil
+	}
+
+	if c.doc.stale() && c.discoveryRetryAllowed() {
+		c.lastDiscoveryAttempt = time.Now()
+		d, err := fetchDiscovery(c.httpClient(), c.Issuer)
+		if err != nil {
+			if c.doc == nil {
+				return nil, err
+			}
+			// Keep using the last-known-good document; discoveryRetryAllowed
+			// above already keeps this from retrying more than once a
+			// minute, so the issuer isn't hammered every Token call.
+		} else {
+			c.doc = d
+		}
+	}
+	if c.doc == nil {
+		return nil, fmt.Errorf("auth: no discovery document for %s; the last fetch failed less than %v ago", c.Issuer, minDiscoveryInterval)
+	}
+
+	tok, err := c.grant(c.doc)
+	if err != nil {
+		return nil, err
+	}
+	c.token = tok
+	return tok, nil
+}
+
+// grant obtains a token using whichever grant the issuer advertises,
+// preferring client_credentials (the common case for service-to-service
+// config fetches) and falling back to jwt-bearer for issuers that only
+// trust a signed platform assertion (e.g. instance identity documents).
+func (c *Client) grant(d *discovery) (*Token, error) {
+	switch {
+	case d.supportsGrant("client_credentials") && c.ClientID != "":
+		return c.clientCredentialsGrant(d)
+	case d.supportsGrant("urn:ietf:params:oauth:grant-type:jwt-bearer"):
+		return c.jwtBearerGrant(d)
+	default:
+		return nil, fmt.Errorf("auth: issuer %s supports no grant type this client can use", c.Issuer)
+	}
+}
+
+func (c *Client) clientCredentialsGrant(d *discovery) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+	return c.postTokenRequest(d.TokenEndpoint, form)
+}
+
+// jwtBearerGrant exchanges a platform-signed assertion for a token. The
+// assertion itself is out of scope for this client; platforms that need it
+// (e.g. a cloud metadata service requiring an IMDSv2-style signed request)
+// are expected to supply one via ClientSecret, reusing the same field to
+// avoid growing the cmdline surface further.
+func (c *Client) jwtBearerGrant(d *discovery) (*Token, error) {
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {c.ClientSecret},
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+	return c.postTokenRequest(d.TokenEndpoint, form)
+}
+
+func (c *Client) postTokenRequest(endpoint string, form url.Values) (*Token, error) {
+	resp, err := c.httpClient().PostForm(endpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("auth: token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: token endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, fmt.Errorf("auth: parsing token response: %v", err)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("auth: token response had no access_token")
+	}
+
+	return &Token{
+		AccessToken: body.AccessToken,
+		ExpiresAt:   time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+	}, nil
+}
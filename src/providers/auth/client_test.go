@@ -0,0 +1,155 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newDiscoveryServer(t *testing.T, tokenHandler http.HandlerFunc) *httptest.Server {
+	var srv *httptest.Server
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(wellKnownSuffix, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                srv.URL,
+			"token_endpoint":        srv.URL + "/token",
+			"grant_types_supported": []string{"client_credentials", "urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		})
+	})
+	mux.HandleFunc("/token", tokenHandler)
+
+	srv = httptest.NewServer(mux)
+	return srv
+}
+
+func TestClientTokenClientCredentials(t *testing.T) {
+	var tokenHits int
+	srv := newDiscoveryServer(t, func(w http.ResponseWriter, r *http.Request) {
+		tokenHits++
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parsing token request: %v", err)
+		}
+		if got := r.FormValue("grant_type"); got != "client_credentials" {
+			t.Fatalf("grant_type = %q, want client_credentials", got)
+		}
+		if got := r.FormValue("client_id"); got != "client-id" {
+			t.Fatalf("client_id = %q, want client-id", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "tok-1",
+			"expires_in":   3600,
+		})
+	})
+	defer srv.Close()
+
+	c := &Client{
+		Issuer:       srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		HTTPClient:   srv.Client(),
+	}
+
+	tok, err := c.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tok.AccessToken != "tok-1" {
+		t.Fatalf("AccessToken = %q, want tok-1", tok.AccessToken)
+	}
+
+	// A second call before expiry should reuse the cached token rather
+	// than hitting the token endpoint again.
+	if _, err := c.Token(); err != nil {
+		t.Fatalf("second Token: %v", err)
+	}
+	if tokenHits != 1 {
+		t.Fatalf("token endpoint hit %d times, want 1 (cached token wasn't reused)", tokenHits)
+	}
+}
+
+func TestClientTokenJWTBearerFallback(t *testing.T) {
+	srv := newDiscoveryServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parsing token request: %v", err)
+		}
+		if got := r.FormValue("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Fatalf("grant_type = %q, want jwt-bearer", got)
+		}
+		if got := r.FormValue("assertion"); got != "platform-assertion" {
+			t.Fatalf("assertion = %q, want platform-assertion", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "tok-2",
+			"expires_in":   3600,
+		})
+	})
+	defer srv.Close()
+
+	// No ClientID means the client_credentials branch is skipped in favor
+	// of jwt-bearer, with the platform assertion carried in ClientSecret.
+	c := &Client{
+		Issuer:       srv.URL,
+		ClientSecret: "platform-assertion",
+		HTTPClient:   srv.Client(),
+	}
+
+	tok, err := c.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if tok.AccessToken != "tok-2" {
+		t.Fatalf("AccessToken = %q, want tok-2", tok.AccessToken)
+	}
+}
+
+func TestClientTokenEndpointError(t *testing.T) {
+	srv := newDiscoveryServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	})
+	defer srv.Close()
+
+	c := &Client{
+		Issuer:       srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "wrong-secret",
+		HTTPClient:   srv.Client(),
+	}
+
+	if _, err := c.Token(); err == nil {
+		t.Fatal("Token: expected an error from a failing token endpoint, got nil")
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	var tok *Token
+	if !tok.Expired() {
+		t.Fatal("a nil *Token should report Expired")
+	}
+
+	tok = &Token{ExpiresAt: time.Now().Add(time.Hour)}
+	if tok.Expired() {
+		t.Fatal("a token with a far-future ExpiresAt should not be Expired")
+	}
+
+	tok = &Token{ExpiresAt: time.Now().Add(time.Second)}
+	if !tok.Expired() {
+		t.Fatal("a token expiring within the 5s skew window should be Expired")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth performs OIDC discovery and OAuth2 token acquisition on
+// behalf of config providers whose backing service requires a bearer
+// token, such as the remote HTTP provider or a cloud metadata service
+// that speaks an IMDSv2-style signed-request protocol.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const wellKnownSuffix = "/.well-known/openid-configuration"
+
+// minDiscoveryInterval and maxDiscoveryInterval bound how often the
+// discovery document is re-fetched: never more than once a minute (to
+// tolerate bursts of Token calls), and at least once a day (in case the
+// issuer rotates its token endpoint or signing keys).
+const (
+	minDiscoveryInterval = time.Minute
+	maxDiscoveryInterval = 24 * time.Hour
+)
+
+// discovery is the subset of the OIDC discovery document
+// (https://openid.net/specs/openid-connect-discovery-1_0.html) that the
+// client needs in order to obtain a token.
+type discovery struct {
+	Issuer              string   `json:"issuer"`
+	TokenEndpoint       string   `json:"token_endpoint"`
+	GrantTypesSupported []string `json:"grant_types_supported"`
+
+	fetchedAt time.Time
+}
+
+func fetchDiscovery(client *http.Client, issuer string) (*discovery, error) {
+	url := strings.TrimRight(issuer, "/") + wellKnownSuffix
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("auth: discovery request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: discovery returned HTTP %d", resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading discovery response: %v", err)
+	}
+
+	var d discovery
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("auth: parsing discovery document: %v", err)
+	}
+	if d.TokenEndpoint == "" {
+		return nil, fmt.Errorf("auth: discovery document for %s has no token_endpoint", issuer)
+	}
+
+	d.fetchedAt = time.Now()
+	return &d, nil
+}
+
+// stale reports whether d is old enough that it should be re-fetched
+// before being trusted again. minDiscoveryInterval doesn't factor in here;
+// it instead rate-limits how often Client.Token retries after a failed
+// fetch, so that is a separate concern.
+func (d *discovery) stale() bool {
+	if d == nil {
+		return true
+	}
+	return time.Since(d.fetchedAt) >= maxDiscoveryInterval
+}
+
+func (d *discovery) supportsGrant(grant string) bool {
+	for _, g := range d.GrantTypesSupported {
+		if g == grant {
+			return true
+		}
+	}
+	// Per spec, a missing grant_types_supported defaults to
+	// authorization_code and implicit; assume client_credentials and
+	// jwt-bearer are available unless explicitly advertised otherwise.
+	return len(d.GrantTypesSupported) == 0
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryStale(t *testing.T) {
+	var nilDoc *discovery
+	if !nilDoc.stale() {
+		t.Fatal("a nil *discovery should report stale")
+	}
+
+	fresh := &discovery{fetchedAt: time.Now()}
+	if fresh.stale() {
+		t.Fatal("a just-fetched discovery document should not report stale")
+	}
+
+	// A document older than minDiscoveryInterval but well under
+	// maxDiscoveryInterval must not be stale: minDiscoveryInterval only
+	// rate-limits retries after a failed fetch, it isn't the everyday
+	// refresh trigger.
+	ninetySecondsOld := &discovery{fetchedAt: time.Now().Add(-90 * time.Second)}
+	if ninetySecondsOld.stale() {
+		t.Fatal("a 90s-old discovery document should not report stale")
+	}
+
+	old := &discovery{fetchedAt: time.Now().Add(-(maxDiscoveryInterval + time.Second))}
+	if !old.stale() {
+		t.Fatal("a discovery document older than maxDiscoveryInterval should report stale")
+	}
+}
+
+func TestClientTokenDiscoveryNotRefetchedWithinMaxInterval(t *testing.T) {
+	var discoveryHits int
+	srv := newDiscoveryServer(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "tok",
+			"expires_in":   1,
+		})
+	})
+	defer srv.Close()
+
+	c := &Client{
+		Issuer:       srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		HTTPClient:   srv.Client(),
+	}
+	// newDiscoveryServer's well-known handler doesn't count hits itself,
+	// so track discovery requests by wrapping the client's transport.
+	c.HTTPClient = &http.Client{Transport: countingTransport{
+		rt: srv.Client().Transport,
+		onRequest: func(r *http.Request) {
+			if r.URL.Path == wellKnownSuffix {
+				discoveryHits++
+			}
+		},
+	}}
+
+	if _, err := c.Token(); err != nil {
+		t.Fatalf("first Token: %v", err)
+	}
+	// Force the cached token to look expired without waiting a real
+	// second, so a second Token call re-enters the discovery.stale()
+	// check.
+	c.token.ExpiresAt = time.Now().Add(-time.Hour)
+	if _, err := c.Token(); err != nil {
+		t.Fatalf("second Token: %v", err)
+	}
+
+	if discoveryHits != 1 {
+		t.Fatalf("discovery endpoint hit %d times, want 1 (cached doc should be reused within maxDiscoveryInterval)", discoveryHits)
+	}
+}
+
+// countingTransport wraps an http.RoundTripper to observe each request
+// before delegating, so tests can count hits to a specific path without
+// the server itself needing instrumentation.
+type countingTransport struct {
+	rt        http.RoundTripper
+	onRequest func(*http.Request)
+}
+
+func (c countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.onRequest(r)
+	return c.rt.RoundTrip(r)
+}
@@ -0,0 +1,30 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+// AuthenticatedFetcher is implemented by providers whose FetchConfig call
+// must first present an OAuth2/OIDC bearer token, e.g. a metadata service
+// that requires an IMDSv2-style signed request. It is optional: most
+// providers (e.g. noop) don't implement it, and remote implements it as a
+// no-op when no auth is configured.
+//
+// The engine calls Authenticate immediately before FetchConfig whenever a
+// provider satisfies this interface, so implementations should cache the
+// token internally and have FetchConfig attach it to whatever request it
+// makes.
+type AuthenticatedFetcher interface {
+	Provider
+	Authenticate() error
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coreos/ignition/src/providers/util"
+)
+
+// cmdlineJWKS names the kernel cmdline option giving the location (a local
+// file path, or an http(s) URL) of the JWKS that fetched configs must be
+// signed against. It's the same provider-independent, cmdline-driven
+// configuration style as coreos.config.auth.issuer.
+const cmdlineJWKS = "coreos.config.verify.jwks"
+
+// VerifyIfConfigured is meant to be called by a provider's FetchConfig,
+// after retrieving the raw config bytes b but before parsing them. If
+// coreos.config.verify.jwks wasn't given on the cmdline, verification
+// wasn't requested and b is returned unchanged. Otherwise b must be a
+// compact JWS verifiable against that key set, and the returned bytes are
+// its unwrapped config payload.
+//
+// A caller whose FetchConfig gets an error back from this should treat the
+// provider as permanently errored rather than retrying: a bad signature
+// won't fix itself on the next poll.
+func VerifyIfConfigured(b []byte) ([]byte, error) {
+	loc, ok := util.Getopt(cmdlineJWKS)
+	if !ok || loc == "" {
+		return b, nil
+	}
+
+	raw, err := fetchKeySet(loc)
+	if err != nil {
+		return nil, fmt.Errorf("jws: fetching key set from %s: %v", loc, err)
+	}
+
+	keys, err := ParseKeySet(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return Verify(b, keys, time.Now())
+}
+
+// fetchKeySet reads the JWKS document at loc, an http(s) URL or a local
+// file path.
+func fetchKeySet(loc string) ([]byte, error) {
+	if u, err := url.Parse(loc); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		resp, err := http.Get(loc)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	return ioutil.ReadFile(loc)
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jws verifies compact JSON Web Signatures against a JSON Web Key
+// Set, so that a provider can refuse a config from a metadata service
+// whose trust a signature can't establish. It implements only the subset
+// of JOSE providers actually need: RS256 and ES256 compact JWS, plus a
+// minimal envelope carrying the standard exp/nbf claims around the actual
+// Ignition config. That's a small enough surface that vendoring a general
+// JOSE library isn't worth the dependency.
+//
+// The signed payload is expected to look like:
+//
+//	{"exp": 1700000000, "nbf": 1690000000, "config": { ...ignition config... }}
+//
+// exp and nbf are both optional; config is not.
+package jws
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	// ErrMalformed is returned when the input isn't a three-part compact
+	// JWS (header.payload.signature).
+	ErrMalformed = errors.New("jws: malformed compact serialization")
+
+	// ErrNoMatchingKey is returned when no key in the set matches the
+	// JWS header's kid (if any) and is usable with its alg.
+	ErrNoMatchingKey = errors.New("jws: no key set entry matches the JWS header")
+
+	// ErrInvalidSignature is returned when the signature doesn't verify
+	// against the matched key.
+	ErrInvalidSignature = errors.New("jws: signature verification failed")
+
+	// ErrNotYetValid and ErrExpired are returned when the payload's nbf
+	// or exp claim, respectively, fails against the current time.
+	ErrNotYetValid = errors.New("jws: payload's nbf claim is in the future")
+	ErrExpired     = errors.New("jws: payload's exp claim is in the past")
+
+	// ErrNoConfig is returned when a payload verifies but carries no
+	// config field to unwrap.
+	ErrNoConfig = errors.New("jws: payload has no config field")
+)
+
+// header is the subset of the JWS protected header this package
+// understands.
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// envelope is the JSON shape of a verified JWS payload: the standard exp
+// and nbf claims wrapped around the actual Ignition config.
+type envelope struct {
+	Exp    *int64          `json:"exp"`
+	Nbf    *int64          `json:"nbf"`
+	Config json.RawMessage `json:"config"`
+}
+
+// Verify checks that compact is a well-formed JWS, signed by a key in
+// keys matching its header, with an exp/nbf (if present) valid at now.
+// On success it returns the raw bytes of the payload's config field,
+// ready to hand to config.Parse.
+func Verify(compact []byte, keys *KeySet, now time.Time) ([]byte, error) {
+	parts := bytes.Split(bytes.TrimSpace(compact), []byte{'.'})
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	headerB64, payloadB64, sigB64 := parts[0], parts[1], parts[2]
+
+	headerJSON, err := decodeSegment(headerB64)
+	if err != nil {
+		return nil, fmt.Errorf("jws: decoding header: %v", err)
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return nil, fmt.Errorf("jws: parsing header: %v", err)
+	}
+
+	payloadJSON, err := decodeSegment(payloadB64)
+	if err != nil {
+		return nil, fmt.Errorf("jws: decoding payload: %v", err)
+	}
+
+	sig, err := decodeSegment(sigB64)
+	if err != nil {
+		return nil, fmt.Errorf("jws: decoding signature: %v", err)
+	}
+
+	key, ok := keys.find(h.Kid, h.Alg)
+	if !ok {
+		return nil, ErrNoMatchingKey
+	}
+
+	signingInput := make([]byte, 0, len(headerB64)+len(payloadB64)+1)
+	signingInput = append(signingInput, headerB64...)
+	signingInput = append(signingInput, '.')
+	signingInput = append(signingInput, payloadB64...)
+
+	if err := verifySignature(h.Alg, key, signingInput, sig); err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(payloadJSON, &env); err != nil {
+		return nil, fmt.Errorf("jws: parsing payload: %v", err)
+	}
+
+	if env.Nbf != nil && now.Before(time.Unix(*env.Nbf, 0)) {
+		return nil, ErrNotYetValid
+	}
+	if env.Exp != nil && now.After(time.Unix(*env.Exp, 0)) {
+		return nil, ErrExpired
+	}
+	if len(env.Config) == 0 {
+		return nil, ErrNoConfig
+	}
+
+	return env.Config, nil
+}
+
+// verifySignature checks sig against signingInput using key, dispatching
+// on the JWS alg. key's concrete type must match alg's expected key type
+// (*rsa.PublicKey for RS256, *ecdsa.PublicKey for ES256); KeySet.find
+// guarantees this.
+func verifySignature(alg string, key interface{}, signingInput, sig []byte) error {
+	sum := sha256.Sum256(signingInput)
+
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrNoMatchingKey
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrNoMatchingKey
+		}
+		// JOSE encodes an ECDSA signature as the concatenation of r and s,
+		// each left-padded to the curve's coordinate size, not ASN.1 DER.
+		if len(sig) != 64 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("jws: unsupported alg %q", alg)
+	}
+}
+
+func decodeSegment(seg []byte) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(seg))
+}
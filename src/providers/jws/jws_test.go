@@ -0,0 +1,257 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// signCompact builds a compact JWS (header.payload.sig) over payload,
+// signed by priv under alg, for use as test fixtures. It's the inverse of
+// Verify, so tests can exercise Verify without a prebuilt fixture file.
+func signCompact(t *testing.T, alg, kid string, priv interface{}, payload []byte) []byte {
+	h := header{Alg: alg, Kid: kid}
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("marshaling header: %v", err)
+	}
+
+	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
+	payloadB64 := base64.RawURLEncoding.EncodeToString(payload)
+	signingInput := headerB64 + "." + payloadB64
+	sum := sha256.Sum256([]byte(signingInput))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, priv.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatalf("signing RS256: %v", err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, priv.(*ecdsa.PrivateKey), sum[:])
+		if err != nil {
+			t.Fatalf("signing ES256: %v", err)
+		}
+		sig = make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rBytes):32], rBytes)
+		copy(sig[64-len(sBytes):64], sBytes)
+	default:
+		t.Fatalf("signCompact: unsupported alg %q", alg)
+	}
+
+	sigB64 := base64.RawURLEncoding.EncodeToString(sig)
+	return []byte(signingInput + "." + sigB64)
+}
+
+// rsaKeySet generates an RSA key pair and returns both the private key (to
+// sign test fixtures) and the KeySet a verifier would be given, wrapping
+// its public half under kid.
+func rsaKeySet(t *testing.T, kid string) (*rsa.PrivateKey, *KeySet) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	return priv, &KeySet{keys: []jwk{{
+		Kid: kid,
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(priv.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big64(priv.PublicKey.E)),
+	}}}
+}
+
+// ecKeySet generates a P-256 key pair and returns both the private key and
+// the KeySet a verifier would be given.
+func ecKeySet(t *testing.T, kid string) (*ecdsa.PrivateKey, *KeySet) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+	return priv, &KeySet{keys: []jwk{{
+		Kid: kid,
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(priv.PublicKey.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(priv.PublicKey.Y.Bytes()),
+	}}}
+}
+
+// big64 encodes a small int (an RSA exponent, e.g. 65537) as the minimal
+// big-endian byte string ParseKeySet/decodeBigInt expects.
+func big64(v int) []byte {
+	var b []byte
+	for v > 0 {
+		b = append([]byte{byte(v & 0xff)}, b...)
+		v >>= 8
+	}
+	if b == nil {
+		b = []byte{0}
+	}
+	return b
+}
+
+func envelopeJSON(t *testing.T, exp, nbf *int64, config string) []byte {
+	env := struct {
+		Exp    *int64          `json:"exp,omitempty"`
+		Nbf    *int64          `json:"nbf,omitempty"`
+		Config json.RawMessage `json:"config"`
+	}{Exp: exp, Nbf: nbf, Config: json.RawMessage(config)}
+
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("marshaling envelope: %v", err)
+	}
+	return b
+}
+
+func TestVerifyRS256(t *testing.T) {
+	priv, keys := rsaKeySet(t, "key-1")
+	payload := envelopeJSON(t, nil, nil, `{"ignition":{"version":"2.1.0"}}`)
+	compact := signCompact(t, "RS256", "key-1", priv, payload)
+
+	cfg, err := Verify(compact, keys, time.Now())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if string(cfg) != `{"ignition":{"version":"2.1.0"}}` {
+		t.Fatalf("config = %s, want the unwrapped payload config field", cfg)
+	}
+}
+
+func TestVerifyES256(t *testing.T) {
+	priv, keys := ecKeySet(t, "key-1")
+	payload := envelopeJSON(t, nil, nil, `{"ignition":{"version":"2.1.0"}}`)
+	compact := signCompact(t, "ES256", "key-1", priv, payload)
+
+	cfg, err := Verify(compact, keys, time.Now())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if string(cfg) != `{"ignition":{"version":"2.1.0"}}` {
+		t.Fatalf("config = %s, want the unwrapped payload config field", cfg)
+	}
+}
+
+func TestVerifyNoKidMatchesSingleKeySet(t *testing.T) {
+	priv, keys := rsaKeySet(t, "key-1")
+	payload := envelopeJSON(t, nil, nil, `{"ignition":{"version":"2.1.0"}}`)
+	// Sign with no kid in the header; find() must still match the lone
+	// RSA entry in the set.
+	compact := signCompact(t, "RS256", "", priv, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestVerifyBadSignature(t *testing.T) {
+	_, keys := rsaKeySet(t, "key-1")
+	other, _ := rsaKeySet(t, "key-1")
+	payload := envelopeJSON(t, nil, nil, `{"ignition":{"version":"2.1.0"}}`)
+	// Sign with a different key than the one in keys, simulating a
+	// forged or corrupted signature.
+	compact := signCompact(t, "RS256", "key-1", other, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != ErrInvalidSignature {
+		t.Fatalf("Verify err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestVerifyNoMatchingKey(t *testing.T) {
+	priv, _ := rsaKeySet(t, "key-1")
+	_, keys := rsaKeySet(t, "key-2")
+	payload := envelopeJSON(t, nil, nil, `{"ignition":{"version":"2.1.0"}}`)
+	compact := signCompact(t, "RS256", "key-1", priv, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != ErrNoMatchingKey {
+		t.Fatalf("Verify err = %v, want ErrNoMatchingKey", err)
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	priv, keys := rsaKeySet(t, "key-1")
+	exp := time.Now().Add(-time.Hour).Unix()
+	payload := envelopeJSON(t, &exp, nil, `{"ignition":{"version":"2.1.0"}}`)
+	compact := signCompact(t, "RS256", "key-1", priv, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != ErrExpired {
+		t.Fatalf("Verify err = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyNotYetValid(t *testing.T) {
+	priv, keys := rsaKeySet(t, "key-1")
+	nbf := time.Now().Add(time.Hour).Unix()
+	payload := envelopeJSON(t, nil, &nbf, `{"ignition":{"version":"2.1.0"}}`)
+	compact := signCompact(t, "RS256", "key-1", priv, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != ErrNotYetValid {
+		t.Fatalf("Verify err = %v, want ErrNotYetValid", err)
+	}
+}
+
+func TestVerifyNoConfig(t *testing.T) {
+	priv, keys := rsaKeySet(t, "key-1")
+	payload := []byte(`{}`)
+	compact := signCompact(t, "RS256", "key-1", priv, payload)
+
+	if _, err := Verify(compact, keys, time.Now()); err != ErrNoConfig {
+		t.Fatalf("Verify err = %v, want ErrNoConfig", err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	if _, err := Verify([]byte("not-a-jws"), &KeySet{}, time.Now()); err != ErrMalformed {
+		t.Fatalf("Verify err = %v, want ErrMalformed", err)
+	}
+}
+
+func TestParseKeySetRoundTrip(t *testing.T) {
+	_, rsaKeys := rsaKeySet(t, "rsa-key")
+	_, ecKeys := ecKeySet(t, "ec-key")
+
+	doc := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa-key","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec-key","crv":"P-256","x":%q,"y":%q}
+	]}`,
+		rsaKeys.keys[0].N, rsaKeys.keys[0].E,
+		ecKeys.keys[0].X, ecKeys.keys[0].Y)
+
+	parsed, err := ParseKeySet([]byte(doc))
+	if err != nil {
+		t.Fatalf("ParseKeySet: %v", err)
+	}
+
+	if _, ok := parsed.find("rsa-key", "RS256"); !ok {
+		t.Fatal("find(rsa-key, RS256) = not found, want the parsed RSA key")
+	}
+	if _, ok := parsed.find("ec-key", "ES256"); !ok {
+		t.Fatal("find(ec-key, ES256) = not found, want the parsed EC key")
+	}
+	if _, ok := parsed.find("rsa-key", "ES256"); ok {
+		t.Fatal("find(rsa-key, ES256) = found, want no match (kty mismatch)")
+	}
+}
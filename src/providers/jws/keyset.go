@@ -0,0 +1,135 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// KeySet is a parsed JSON Web Key Set (RFC 7517): the trusted public keys
+// a config's JWS signature is checked against.
+type KeySet struct {
+	keys []jwk
+}
+
+// jwk is the subset of JSON Web Key fields needed to reconstruct an RSA or
+// EC public key.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet parses raw as a JWKS document ({"keys": [...]}).
+func ParseKeySet(raw []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("jws: parsing key set: %v", err)
+	}
+	return &KeySet{keys: doc.Keys}, nil
+}
+
+// find returns the public key of the first entry matching kid (if kid is
+// non-empty) whose key type is compatible with alg, along with whether
+// such an entry was found. A JWS with no kid is matched against every
+// entry of the right type, so a single-key JWKS doesn't need one.
+func (ks *KeySet) find(kid, alg string) (interface{}, bool) {
+	if ks == nil {
+		return nil, false
+	}
+
+	for _, k := range ks.keys {
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		if !keyTypeSupportsAlg(k.Kty, alg) {
+			continue
+		}
+
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		return pub, true
+	}
+	return nil, false
+}
+
+func keyTypeSupportsAlg(kty, alg string) bool {
+	switch alg {
+	case "RS256":
+		return kty == "RSA"
+	case "ES256":
+		return kty == "EC"
+	default:
+		return false
+	}
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jws: decoding RSA modulus: %v", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jws: decoding RSA exponent: %v", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jws: unsupported EC curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jws: decoding EC x coordinate: %v", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jws: decoding EC y coordinate: %v", err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("jws: unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// statusError wraps a non-2xx HTTP response so that callers can distinguish
+// terminal client errors (404, 410) from retryable server errors.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("remote: unexpected HTTP status %d", e.code)
+}
+
+func fetchHTTP(client *http.Client, u *url.URL) ([]byte, error) {
+	return fetchHTTPWithToken(client, u, "")
+}
+
+// fetchHTTPWithToken is like fetchHTTP but, when token is non-empty, attaches
+// it as a bearer Authorization header. This is how providers that satisfy
+// providers.AuthenticatedFetcher present the token obtained via
+// src/providers/auth.
+func fetchHTTPWithToken(client *http.Client, u *url.URL, token string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,301 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The remote provider fetches a raw Ignition config from an arbitrary URL
+// supplied via the coreos.config.url kernel parameter. It exists for
+// platforms and OEMs that have no metadata service of their own, and is
+// also meant to be called directly (see Fetch) by providers whose metadata
+// service merely returns a pointer URL rather than the config itself.
+
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coreos/ignition/config"
+	"github.com/coreos/ignition/src/log"
+	"github.com/coreos/ignition/src/providers"
+	"github.com/coreos/ignition/src/providers/auth"
+	"github.com/coreos/ignition/src/providers/jws"
+	"github.com/coreos/ignition/src/providers/util"
+)
+
+const (
+	name = "remote"
+
+	cmdlineURL    = "coreos.config.url"
+	cmdlineCACert = "coreos.config.cacert"
+
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+var (
+	// ErrNoURL is returned when no coreos.config.url kernel parameter is
+	// present, making this provider permanently offline.
+	ErrNoURL = errors.New("remote: no " + cmdlineURL + " kernel parameter found")
+)
+
+func init() {
+	providers.Register(creator{})
+}
+
+type creator struct{}
+
+func (creator) Name() string {
+	return name
+}
+
+func (creator) Create(logger log.Logger) providers.Provider {
+	p := &provider{logger: logger}
+	p.configure()
+	return p
+}
+
+// provider polls a single, fixed URL until it can be fetched successfully.
+type provider struct {
+	logger log.Logger
+
+	url    *url.URL
+	client *http.Client
+
+	authClient *auth.Client
+	token      string
+
+	attempt  int
+	terminal bool
+	lastErr  error
+}
+
+// configure resolves the target URL and TLS material from the kernel
+// cmdline. Failures are recorded on the provider instead of returned so
+// that IsOnline/ShouldRetry can surface them through the normal polling
+// path rather than special-casing construction errors.
+func (p *provider) configure() {
+	raw, ok := util.Getopt(cmdlineURL)
+	if !ok {
+		p.lastErr = ErrNoURL
+		p.terminal = true
+		return
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		p.lastErr = fmt.Errorf("remote: invalid %s: %v", cmdlineURL, err)
+		p.terminal = true
+		return
+	}
+	p.url = u
+
+	pool, err := certPool()
+	if err != nil {
+		p.lastErr = err
+		p.terminal = true
+		return
+	}
+
+	p.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	if c, ok := auth.NewClientFromCmdline(); ok {
+		c.HTTPClient = p.client
+		p.authClient = c
+	}
+}
+
+// Authenticate satisfies providers.AuthenticatedFetcher. It is a no-op when
+// no coreos.config.auth.issuer was given on the cmdline.
+func (p *provider) Authenticate() error {
+	if p.authClient == nil {
+		return nil
+	}
+
+	tok, err := p.authClient.Token()
+	if err != nil {
+		return fmt.Errorf("remote: authenticating: %v", err)
+	}
+	p.token = tok.AccessToken
+	return nil
+}
+
+// certPool returns the system CA pool, augmented with the user-supplied
+// bundle named by the coreos.config.cacert kernel parameter, if any.
+func certPool() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	path, ok := util.Getopt(cmdlineCACert)
+	if !ok {
+		return pool, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("remote: reading %s: %v", cmdlineCACert, err)
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("remote: no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+func (provider) Name() string {
+	return name
+}
+
+func (p *provider) FetchConfig() (config.Config, error) {
+	b, err := p.fetch()
+	if err != nil {
+		p.lastErr = err
+		return config.Config{}, err
+	}
+
+	// If coreos.config.verify.jwks was given on the cmdline, b must be a
+	// compact JWS signed by a trusted key; a bad signature is never worth
+	// retrying, so it permanently fails the provider rather than just
+	// this attempt.
+	b, err = jws.VerifyIfConfigured(b)
+	if err != nil {
+		p.lastErr = err
+		p.terminal = true
+		return config.Config{}, err
+	}
+
+	p.lastErr = nil
+	return config.Parse(b)
+}
+
+// fetch retrieves the raw bytes at p.url, dispatching on scheme.
+func (p *provider) fetch() ([]byte, error) {
+	switch p.url.Scheme {
+	case "http", "https":
+		return fetchHTTPWithToken(p.client, p.url, p.token)
+	case "tftp":
+		return fetchTFTP(p.url)
+	default:
+		return nil, fmt.Errorf("remote: unsupported scheme %q", p.url.Scheme)
+	}
+}
+
+func (p *provider) IsOnline() bool {
+	if p.terminal {
+		return false
+	}
+
+	// A metadata service that requires a bearer token will 401/403 an
+	// unauthenticated probe forever, so get one (Authenticate caches it
+	// and is a no-op without coreos.config.auth.issuer) before fetching.
+	// The engine also calls Authenticate once a provider is selected as
+	// online; that second call is a cache hit off p.authClient.Token.
+	if err := p.Authenticate(); err != nil {
+		p.attempt++
+		p.lastErr = err
+		return false
+	}
+
+	b, err := p.fetch()
+	if err != nil {
+		p.attempt++
+		p.lastErr = err
+		if terminalError(err) {
+			p.terminal = true
+		}
+		return false
+	}
+
+	// When coreos.config.verify.jwks is configured, b is a compact JWS
+	// and config.Parse would fail on it directly every time; run it
+	// through the same verify-then-parse path as FetchConfig so this
+	// probe actually reflects what FetchConfig will see.
+	b, err = jws.VerifyIfConfigured(b)
+	if err != nil {
+		p.lastErr = err
+		p.terminal = true
+		return false
+	}
+
+	_, err = config.Parse(b)
+	p.lastErr = err
+	return err == nil
+}
+
+func (p provider) ShouldRetry() bool {
+	return !p.terminal
+}
+
+// LastError satisfies providers.ErrorReporter.
+func (p *provider) LastError() error {
+	return p.lastErr
+}
+
+func (p *provider) BackoffDuration() time.Duration {
+	return util.Backoff(p.attempt, initialBackoff, maxBackoff)
+}
+
+// terminalError reports whether err represents a condition that will never
+// resolve itself by retrying, such as a 404 from the remote server.
+func terminalError(err error) bool {
+	se, ok := err.(*statusError)
+	if !ok {
+		return false
+	}
+	return se.code == http.StatusNotFound || se.code == http.StatusGone
+}
+
+// Fetch retrieves and parses the Ignition config at rawURL using client (or
+// http.DefaultClient if nil). It is exported so that cloud providers whose
+// metadata service merely returns a pointer URL can chain to it instead of
+// duplicating the scheme-dispatch and verification logic above. It makes a
+// single attempt; the provider's retry and backoff handling does not apply.
+func Fetch(rawURL string, client *http.Client) (config.Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	var b []byte
+	switch u.Scheme {
+	case "http", "https":
+		b, err = fetchHTTP(client, u)
+	case "tftp":
+		b, err = fetchTFTP(u)
+	default:
+		return config.Config{}, fmt.Errorf("remote: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	// Same as FetchConfig/IsOnline: if coreos.config.verify.jwks was
+	// given on the cmdline, b is a compact JWS that must be verified
+	// before it's treated as an Ignition config, not raw config bytes.
+	b, err = jws.VerifyIfConfigured(b)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	return config.Parse(b)
+}
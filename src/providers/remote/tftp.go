@@ -0,0 +1,141 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A minimal RFC 1350 TFTP client supporting octet-mode GET, which is all
+// that's needed to pull down a config file staged alongside a PXE image.
+
+const (
+	tftpBlockSize = 512
+	tftpRetries   = 5
+
+	opRRQ  = 1
+	opDATA = 3
+	opACK  = 4
+	opERR  = 5
+)
+
+// tftpTimeout is a var, not a const, so tests can shrink it rather than
+// waiting out a real 5s read deadline to exercise the retry path.
+var tftpTimeout = 5 * time.Second
+
+func fetchTFTP(u *url.URL) ([]byte, error) {
+	addr := u.Host
+	if !strings.Contains(addr, ":") {
+		addr += ":69"
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if err := tftpSendRRQ(conn, path); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	expected := uint16(1)
+	retries := 0
+	buf := make([]byte, tftpBlockSize+4)
+	for {
+		conn.SetReadDeadline(time.Now().Add(tftpTimeout))
+		n, err := conn.Read(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// UDP drops packets in the normal case, not just on
+				// failure; re-ACK the last block received (or re-send
+				// the RRQ if none has arrived yet) rather than treating
+				// a single lost packet as a fatal error.
+				if retries >= tftpRetries {
+					return nil, fmt.Errorf("remote: tftp read: timed out after %d retries", tftpRetries)
+				}
+				retries++
+				if expected == 1 {
+					if err := tftpSendRRQ(conn, path); err != nil {
+						return nil, err
+					}
+				} else if err := tftpSendACK(conn, expected-1); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, fmt.Errorf("remote: tftp read: %v", err)
+		}
+		if n < 4 {
+			return nil, fmt.Errorf("remote: tftp: short packet")
+		}
+
+		op := binary.BigEndian.Uint16(buf[0:2])
+		switch op {
+		case opERR:
+			if n < 5 {
+				return nil, fmt.Errorf("remote: tftp error (no message)")
+			}
+			return nil, fmt.Errorf("remote: tftp error: %s", buf[4:n-1])
+		case opDATA:
+			block := binary.BigEndian.Uint16(buf[2:4])
+			if block != expected {
+				continue
+			}
+			retries = 0
+			out.Write(buf[4:n])
+			if err := tftpSendACK(conn, block); err != nil {
+				return nil, err
+			}
+			expected++
+			if n-4 < tftpBlockSize {
+				return out.Bytes(), nil
+			}
+		default:
+			return nil, fmt.Errorf("remote: tftp: unexpected opcode %d", op)
+		}
+	}
+}
+
+func tftpSendRRQ(conn *net.UDPConn, path string) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(opRRQ))
+	buf.WriteString(path)
+	buf.WriteByte(0)
+	buf.WriteString("octet")
+	buf.WriteByte(0)
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+func tftpSendACK(conn *net.UDPConn, block uint16) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint16(buf[0:2], opACK)
+	binary.BigEndian.PutUint16(buf[2:4], block)
+	_, err := conn.Write(buf[:])
+	return err
+}
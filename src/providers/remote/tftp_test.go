@@ -0,0 +1,179 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTFTPServer listens on a loopback UDP socket and replies to every
+// packet it receives with the bytes returned by respond, until the test
+// closes the returned connection.
+func fakeTFTPServer(t *testing.T, respond func(pkt []byte) []byte) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			reply := respond(buf[:n])
+			if reply != nil {
+				conn.WriteToUDP(reply, addr)
+			}
+		}
+	}()
+
+	return conn
+}
+
+func tftpURL(t *testing.T, conn *net.UDPConn, path string) *url.URL {
+	u, err := url.Parse(fmt.Sprintf("tftp://%s/%s", conn.LocalAddr().String(), path))
+	if err != nil {
+		t.Fatalf("parsing tftp URL: %v", err)
+	}
+	return u
+}
+
+func TestFetchTFTPSingleBlock(t *testing.T) {
+	want := []byte(`{"ignition":{"version":"2.1.0"}}`)
+
+	conn := fakeTFTPServer(t, func(pkt []byte) []byte {
+		if binary.BigEndian.Uint16(pkt[0:2]) != opRRQ {
+			return nil
+		}
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(opDATA))
+		binary.Write(&buf, binary.BigEndian, uint16(1))
+		buf.Write(want)
+		return buf.Bytes()
+	})
+	defer conn.Close()
+
+	got, err := fetchTFTP(tftpURL(t, conn, "config.ign"))
+	if err != nil {
+		t.Fatalf("fetchTFTP: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("fetchTFTP = %q, want %q", got, want)
+	}
+}
+
+func TestFetchTFTPErrorWithMessage(t *testing.T) {
+	conn := fakeTFTPServer(t, func(pkt []byte) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(opERR))
+		binary.Write(&buf, binary.BigEndian, uint16(1))
+		buf.WriteString("file not found")
+		buf.WriteByte(0)
+		return buf.Bytes()
+	})
+	defer conn.Close()
+
+	_, err := fetchTFTP(tftpURL(t, conn, "missing.ign"))
+	if err == nil {
+		t.Fatal("fetchTFTP: expected an error from an ERR packet, got nil")
+	}
+}
+
+// TestFetchTFTPRetriesOnTimeout is a regression test for tftpRetries: the
+// first RRQ is dropped on the floor, simulating the packet loss UDP gives
+// no other warning of, and fetchTFTP must retransmit rather than failing
+// on the first read timeout.
+func TestFetchTFTPRetriesOnTimeout(t *testing.T) {
+	old := tftpTimeout
+	tftpTimeout = 50 * time.Millisecond
+	defer func() { tftpTimeout = old }()
+
+	want := []byte(`{"ignition":{"version":"2.1.0"}}`)
+	var rrqs int32
+
+	conn := fakeTFTPServer(t, func(pkt []byte) []byte {
+		if binary.BigEndian.Uint16(pkt[0:2]) != opRRQ {
+			return nil
+		}
+		if atomic.AddInt32(&rrqs, 1) == 1 {
+			// Drop the first RRQ entirely.
+			return nil
+		}
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(opDATA))
+		binary.Write(&buf, binary.BigEndian, uint16(1))
+		buf.Write(want)
+		return buf.Bytes()
+	})
+	defer conn.Close()
+
+	got, err := fetchTFTP(tftpURL(t, conn, "config.ign"))
+	if err != nil {
+		t.Fatalf("fetchTFTP: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("fetchTFTP = %q, want %q", got, want)
+	}
+	if atomic.LoadInt32(&rrqs) < 2 {
+		t.Fatalf("RRQ sent %d times, want at least 2 (a retry after the dropped one)", rrqs)
+	}
+}
+
+// TestFetchTFTPGivesUpAfterRetries is a regression test: once tftpRetries
+// read timeouts have passed with no reply at all, fetchTFTP must return an
+// error instead of retrying forever.
+func TestFetchTFTPGivesUpAfterRetries(t *testing.T) {
+	old := tftpTimeout
+	tftpTimeout = 10 * time.Millisecond
+	defer func() { tftpTimeout = old }()
+
+	conn := fakeTFTPServer(t, func(pkt []byte) []byte {
+		return nil
+	})
+	defer conn.Close()
+
+	_, err := fetchTFTP(tftpURL(t, conn, "config.ign"))
+	if err == nil {
+		t.Fatal("fetchTFTP: expected an error after exhausting retries, got nil")
+	}
+}
+
+// TestFetchTFTPErrorNoMessage is a regression test: a 4-byte ERR packet
+// (opcode + error code, no null-terminated message) used to panic on
+// buf[4:n-1] with a slice-bounds-out-of-range instead of returning an
+// error.
+func TestFetchTFTPErrorNoMessage(t *testing.T) {
+	conn := fakeTFTPServer(t, func(pkt []byte) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.BigEndian, uint16(opERR))
+		binary.Write(&buf, binary.BigEndian, uint16(1))
+		return buf.Bytes()
+	})
+	defer conn.Close()
+
+	_, err := fetchTFTP(tftpURL(t, conn, "missing.ign"))
+	if err == nil {
+		t.Fatal("fetchTFTP: expected an error from a short ERR packet, got nil")
+	}
+}
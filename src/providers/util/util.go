@@ -0,0 +1,74 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package util holds small helpers shared by the config providers, mostly
+// around reading kernel cmdline options and computing retry backoffs.
+package util
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const defaultCmdlinePath = "/proc/cmdline"
+
+// ReadCmdline parses the kernel cmdline at path into a map of option name to
+// value. Options with no "=value" are recorded with an empty string so
+// callers can still detect their presence.
+func ReadCmdline(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := map[string]string{}
+	for _, field := range strings.Fields(string(b)) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) == 2 {
+			opts[parts[0]] = parts[1]
+		} else {
+			opts[parts[0]] = ""
+		}
+	}
+	return opts, nil
+}
+
+// Getopt returns the value of the named kernel cmdline option, and whether it
+// was present at all.
+func Getopt(name string) (string, bool) {
+	opts, err := ReadCmdline(defaultCmdlinePath)
+	if err != nil {
+		return "", false
+	}
+	v, ok := opts[name]
+	return v, ok
+}
+
+// Backoff returns an exponential backoff duration for the given zero-based
+// attempt number, capped at max and jittered by +/-20% so that many nodes
+// retrying the same endpoint don't do so in lockstep.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base * time.Duration(uint64(1)<<uint(attempt))
+	if d <= 0 || d > max {
+		d = max
+	}
+	return jitter(d, 0.2)
+}
+
+func jitter(d time.Duration, frac float64) time.Duration {
+	delta := float64(d) * frac * (rand.Float64()*2 - 1)
+	return d + time.Duration(delta)
+}